@@ -0,0 +1,51 @@
+package resetter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ResetterMetrics holds the Prometheus instrumentation for an IndexResetter.
+type ResetterMetrics struct {
+	Errors             prometheus.Counter
+	IndexResets        prometheus.Counter
+	IndexResetFailures prometheus.Counter
+
+	// LastRunTimestamp records the Unix timestamp of the most recent successful
+	// ResetStalledIndexes call, so operators can alert when the resetter is wedged.
+	LastRunTimestamp prometheus.Gauge
+
+	// BackoffSeconds records the backoff currently being waited out after consecutive
+	// ResetStalledIndexes failures, and is reset to zero on the next success.
+	BackoffSeconds prometheus.Gauge
+}
+
+// NewResetterMetrics registers and returns the counters and gauges for an IndexResetter
+// against r. Use a scoped prometheus.Registerer (rather than reusing one that already has
+// these metrics registered, such as in a test) to avoid a duplicate-registration panic.
+func NewResetterMetrics(r prometheus.Registerer) ResetterMetrics {
+	factory := promauto.With(r)
+
+	return ResetterMetrics{
+		Errors: factory.NewCounter(prometheus.CounterOpts{
+			Name: "src_precise_code_intel_index_resetter_errors_total",
+			Help: "The number of errors that occur when resetting stalled indexes.",
+		}),
+		IndexResets: factory.NewCounter(prometheus.CounterOpts{
+			Name: "src_precise_code_intel_index_resetter_resets_total",
+			Help: "The number of indexes that have been reset from PROCESSING to QUEUED.",
+		}),
+		IndexResetFailures: factory.NewCounter(prometheus.CounterOpts{
+			Name: "src_precise_code_intel_index_resetter_reset_failures_total",
+			Help: "The number of indexes that have been moved to ERRORED after exceeding their reset attempts.",
+		}),
+		LastRunTimestamp: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "src_precise_code_intel_index_resetter_last_run_timestamp_seconds",
+			Help: "The Unix timestamp of the most recent successful stalled index reset run.",
+		}),
+		BackoffSeconds: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "src_precise_code_intel_index_resetter_backoff_seconds",
+			Help: "The current backoff duration, in seconds, after consecutive reset failures.",
+		}),
+	}
+}
@@ -2,29 +2,99 @@ package resetter
 
 import (
 	"context"
+	"math/rand"
 	"time"
 
 	"github.com/inconshreveable/log15"
 	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/store"
 )
 
+// minBackoffInterval and maxBackoffInterval bound the exponential backoff applied after
+// consecutive ResetStalledIndexes failures, when the resetter's own Min/MaxBackoffInterval
+// fields are left unset.
+const (
+	minBackoffInterval = time.Second
+	maxBackoffInterval = 5 * time.Minute
+)
+
 type IndexResetter struct {
 	Store         store.Store
 	ResetInterval time.Duration
 	Metrics       ResetterMetrics
+
+	// MinBackoffInterval and MaxBackoffInterval bound the exponential backoff applied
+	// after consecutive ResetStalledIndexes failures. They default to minBackoffInterval
+	// and maxBackoffInterval, respectively, when left zero.
+	MinBackoffInterval time.Duration
+	MaxBackoffInterval time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start begins periodically moving all indexes that have been in the PROCESSING state for
+// a while back to QUEUED, until ctx is canceled or Stop is called. For each updated index
+// record, the indexer process that was responsible for handling the index did not hold a
+// row lock, indicating that it has died.
+//
+// Start returns immediately; the resetter runs on its own goroutine, in the same shape as
+// the other background routines registered with the process's goroutine supervisor so that
+// it drains cleanly on shutdown.
+func (r *IndexResetter) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		r.run(ctx)
+	}()
 }
 
-// Run periodically moves all indexes that have been in the PROCESSING state for a
-// while back to QUEUED. For each updated index record, the indexer process that
-// was responsible for handling the index did not hold a row lock, indicating that
-// it has died.
-func (ur *IndexResetter) Run() {
+// Stop cancels the context passed to Start and waits for the resetter's goroutine to
+// return. It is safe to call Stop without having called Start.
+func (r *IndexResetter) Stop() {
+	if r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+	<-r.done
+}
+
+func (r *IndexResetter) run(ctx context.Context) {
+	ticker := time.NewTicker(r.ResetInterval)
+	defer ticker.Stop()
+
+	var consecutiveFailures int
+
 	for {
-		resetIDs, erroredIDs, err := ur.Store.ResetStalledIndexes(context.Background(), time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		resetIDs, erroredIDs, err := r.Store.ResetStalledIndexes(ctx, time.Now())
 		if err != nil {
-			ur.Metrics.Errors.Inc()
+			r.Metrics.Errors.Inc()
 			log15.Error("Failed to reset stalled indexes", "error", err)
+
+			backoff := r.backoffDuration(consecutiveFailures)
+			consecutiveFailures++
+			r.Metrics.BackoffSeconds.Set(backoff.Seconds())
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			continue
 		}
+
+		consecutiveFailures = 0
+		r.Metrics.BackoffSeconds.Set(0)
+		r.Metrics.LastRunTimestamp.Set(float64(time.Now().Unix()))
+
 		for _, id := range resetIDs {
 			log15.Debug("Reset stalled index", "indexID", id)
 		}
@@ -32,8 +102,31 @@ func (ur *IndexResetter) Run() {
 			log15.Debug("Failed stalled index", "indexID", id)
 		}
 
-		ur.Metrics.IndexResets.Add(float64(len(resetIDs)))
-		ur.Metrics.IndexResetFailures.Add(float64(len(erroredIDs)))
-		time.Sleep(ur.ResetInterval)
+		r.Metrics.IndexResets.Add(float64(len(resetIDs)))
+		r.Metrics.IndexResetFailures.Add(float64(len(erroredIDs)))
 	}
 }
+
+// backoffDuration returns the jittered exponential backoff to wait after the given number
+// of consecutive failures (zero after a success or before the first failure), doubling
+// with each additional failure and capped at MaxBackoffInterval.
+func (r *IndexResetter) backoffDuration(consecutiveFailures int) time.Duration {
+	min := r.MinBackoffInterval
+	if min <= 0 {
+		min = minBackoffInterval
+	}
+	max := r.MaxBackoffInterval
+	if max <= 0 {
+		max = maxBackoffInterval
+	}
+
+	backoff := min << consecutiveFailures
+	if backoff <= 0 || backoff > max {
+		// backoff <= 0 covers the left-shift overflowing after enough failures.
+		backoff = max
+	}
+
+	// Full jitter: a uniformly random duration between zero and the computed backoff,
+	// so that many resetters backing off at once don't all retry in lockstep.
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
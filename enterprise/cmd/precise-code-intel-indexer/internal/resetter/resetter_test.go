@@ -0,0 +1,84 @@
+package resetter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/store"
+)
+
+func TestIndexResetterStartStop(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.ResetStalledIndexesFunc.SetDefaultHook(func(ctx context.Context, now time.Time) ([]int, []int, error) {
+		return []int{1}, nil, nil
+	})
+
+	resetter := &IndexResetter{
+		Store:         mockStore,
+		ResetInterval: time.Millisecond,
+		Metrics:       NewResetterMetrics(prometheus.NewRegistry()),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resetter.Start(ctx)
+
+	for i := 0; i < 100 && len(mockStore.ResetStalledIndexesFunc.History()) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	resetter.Stop()
+
+	if calls := len(mockStore.ResetStalledIndexesFunc.History()); calls == 0 {
+		t.Fatalf("expected ResetStalledIndexes to have been called at least once")
+	}
+}
+
+func TestIndexResetterStopBeforeStart(t *testing.T) {
+	// Stop must be a no-op (not a nil-pointer panic) when Start was never called.
+	(&IndexResetter{}).Stop()
+}
+
+func TestBackoffDurationGrowsAndCaps(t *testing.T) {
+	resetter := &IndexResetter{
+		MinBackoffInterval: time.Second,
+		MaxBackoffInterval: 4 * time.Second,
+	}
+
+	// The backoff is jittered, so we sample many draws per attempt count and look at the
+	// maximum observed: it must never exceed MaxBackoffInterval, and -- because the
+	// pre-jitter ceiling doubles with each consecutive failure -- the observed maximum
+	// for a high attempt count must be substantially larger than for a low one.
+	maxObserved := func(attempt int) time.Duration {
+		var max time.Duration
+		for i := 0; i < 200; i++ {
+			if d := resetter.backoffDuration(attempt); d > max {
+				max = d
+			}
+			if d := resetter.backoffDuration(attempt); d < 0 || d > resetter.MaxBackoffInterval {
+				t.Fatalf("backoff at attempt %d out of bounds: %s", attempt, d)
+			}
+		}
+		return max
+	}
+
+	if low, high := maxObserved(0), maxObserved(10); high <= low {
+		t.Fatalf("expected backoff ceiling to grow with consecutive failures, got low=%s high=%s", low, high)
+	}
+	if high := maxObserved(10); high < resetter.MaxBackoffInterval/2 {
+		t.Fatalf("expected backoff to approach MaxBackoffInterval once failures saturate it, got %s", high)
+	}
+}
+
+func TestBackoffDurationDefaults(t *testing.T) {
+	resetter := &IndexResetter{}
+
+	d := resetter.backoffDuration(0)
+	if d < 0 || d > maxBackoffInterval {
+		t.Fatalf("expected default backoff to fall within [0, %s], got %s", maxBackoffInterval, d)
+	}
+}
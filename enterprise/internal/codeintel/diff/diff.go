@@ -0,0 +1,261 @@
+// Package diff provides a structured representation of a unified diff, built
+// once from the raw output of `git diff` and then queried repeatedly. It
+// replaces ad-hoc per-line prefix checks (`strings.HasPrefix(line, "+")`) with
+// a typed model that also accounts for "no newline at end of file" markers and
+// combined (merge) diffs.
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineKind describes how a single line of a hunk's body relates to the source
+// and target files.
+type LineKind int
+
+const (
+	// Context lines are present, unchanged, in both the source and target file.
+	Context LineKind = iota
+	// Added lines are present only in the target file.
+	Added
+	// Removed lines are present only in the source file.
+	Removed
+	// NoNewline represents a `\ No newline at end of file` marker. It does not
+	// correspond to a line in either file and carries no line numbers.
+	NoNewline
+)
+
+func (k LineKind) String() string {
+	switch k {
+	case Context:
+		return "context"
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case NoNewline:
+		return "no-newline"
+	default:
+		return "unknown"
+	}
+}
+
+// Line is a single line of a hunk's body, tagged with its kind and its
+// (one-indexed) line number in the source and/or target file. OrigLineNo and
+// NewLineNo are zero when the line has no equivalent in that file.
+type Line struct {
+	Kind       LineKind
+	Content    string
+	OrigLineNo int
+	NewLineNo  int
+}
+
+// Hunk is a single contiguous region of change within a file, along with the
+// fully-parsed lines of its body. OrigStartLine/NewStartLine and
+// OrigLines/NewLines carry the same meaning as the `@@ -l,s +l,s @@` header
+// they were parsed from.
+//
+// Parents is 1 for an ordinary two-way diff and greater than 1 for a combined
+// diff produced by `git diff --cc`/`-c` (e.g. a merge commit diff), where the
+// hunk header carries one `-` range per parent.
+type Hunk struct {
+	OrigStartLine int
+	OrigLines     int
+	NewStartLine  int
+	NewLines      int
+	Parents       int
+	Lines         []Line
+}
+
+// OrigEndLine returns the line immediately following the last line of this
+// hunk in the source file.
+func (h *Hunk) OrigEndLine() int { return h.OrigStartLine + h.OrigLines }
+
+// NewEndLine returns the line immediately following the last line of this
+// hunk in the target file.
+func (h *Hunk) NewEndLine() int { return h.NewStartLine + h.NewLines }
+
+// Patch is the fully-parsed diff of a single file, as a sequence of hunks
+// ordered by their source line.
+type Patch struct {
+	Hunks []*Hunk
+}
+
+// FindHunk returns the last hunk that does not begin after the given
+// one-indexed source line, or nil if line precedes every hunk. Hunks are
+// assumed to be ordered (as they are when produced by Parse), so this runs in
+// O(log n) rather than scanning every hunk.
+func (p *Patch) FindHunk(line int) *Hunk {
+	if p == nil {
+		return nil
+	}
+
+	i := sort.Search(len(p.Hunks), func(i int) bool {
+		return p.Hunks[i].OrigStartLine > line
+	})
+	if i == 0 {
+		return nil
+	}
+	return p.Hunks[i-1]
+}
+
+var (
+	hunkHeaderPattern = regexp.MustCompile(`^@{2,}\s*(.+?)\s*@{2,}`)
+	rangePattern      = regexp.MustCompile(`^([-+])(\d+)(?:,(\d+))?$`)
+)
+
+// Parse builds a Patch from the raw output of `git diff` (or `git diff --cc`)
+// for a single file. Lines outside of any hunk (the `diff --git`, `---`,
+// `+++`, and `index` header lines) are ignored.
+func Parse(raw []byte) (*Patch, error) {
+	patch := &Patch{}
+
+	var cur *Hunk
+	var origLine, newLine int
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "@@") {
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+
+			cur = hunk
+			patch.Hunks = append(patch.Hunks, cur)
+			origLine = cur.OrigStartLine
+			newLine = cur.NewStartLine
+			continue
+		}
+
+		if cur == nil {
+			// File header line (diff --git, index, ---, +++, etc).
+			continue
+		}
+
+		if strings.HasPrefix(line, `\`) {
+			cur.Lines = append(cur.Lines, Line{Kind: NoNewline, Content: line})
+			continue
+		}
+
+		prefixLen := cur.Parents
+		prefix := line
+		if len(prefix) > prefixLen {
+			prefix = prefix[:prefixLen]
+		}
+
+		kind := classifyPrefix(prefix)
+		content := ""
+		if len(line) > prefixLen {
+			content = line[prefixLen:]
+		}
+
+		parsed := Line{Kind: kind, Content: content}
+		switch kind {
+		case Added:
+			parsed.NewLineNo = newLine
+			newLine++
+		case Removed:
+			parsed.OrigLineNo = origLine
+			origLine++
+		default: // Context
+			parsed.OrigLineNo = origLine
+			parsed.NewLineNo = newLine
+			origLine++
+			newLine++
+		}
+
+		cur.Lines = append(cur.Lines, parsed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning diff: %w", err)
+	}
+
+	return patch, nil
+}
+
+// classifyPrefix determines the kind of a hunk body line from its leading
+// per-parent prefix characters (one character for an ordinary diff, one per
+// parent for a combined diff). The line is Added only if every parent marks
+// it as added, Removed if any parent marks it as removed, and Context
+// otherwise.
+func classifyPrefix(prefix string) LineKind {
+	sawRemoved := false
+	allAdded := len(prefix) > 0
+	for _, c := range prefix {
+		switch c {
+		case '+':
+			// added relative to this parent
+		case '-':
+			sawRemoved = true
+			allAdded = false
+		default:
+			allAdded = false
+		}
+	}
+	if sawRemoved {
+		return Removed
+	}
+	if allAdded {
+		return Added
+	}
+	return Context
+}
+
+// parseHunkHeader parses a `@@ -l,s +l,s @@` (or combined `@@@ -l,s -l,s +l,s
+// @@@`) header line into a Hunk with its line-count fields populated.
+func parseHunkHeader(line string) (*Hunk, error) {
+	m := hunkHeaderPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("malformed hunk header: %q", line)
+	}
+
+	fields := strings.Fields(m[1])
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("malformed hunk header: %q", line)
+	}
+
+	hunk := &Hunk{Parents: len(fields) - 1}
+
+	for i, field := range fields {
+		rm := rangePattern.FindStringSubmatch(field)
+		if rm == nil {
+			return nil, fmt.Errorf("malformed hunk range %q in header %q", field, line)
+		}
+
+		start, err := strconv.Atoi(rm[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed hunk range %q in header %q: %w", field, line, err)
+		}
+
+		count := 1
+		if rm[3] != "" {
+			count, err = strconv.Atoi(rm[3])
+			if err != nil {
+				return nil, fmt.Errorf("malformed hunk range %q in header %q: %w", field, line, err)
+			}
+		}
+
+		isLast := i == len(fields)-1
+		if isLast {
+			hunk.NewStartLine, hunk.NewLines = start, count
+		} else if i == 0 {
+			// The first `-` range is the one we track as the hunk's source
+			// range; for a combined diff the remaining `-` ranges describe
+			// the other parents and are only used to classify body lines.
+			hunk.OrigStartLine, hunk.OrigLines = start, count
+		}
+	}
+
+	return hunk, nil
+}
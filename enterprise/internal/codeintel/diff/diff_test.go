@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestParseMultiHunk(t *testing.T) {
+	raw := `diff --git a/foo.go b/foo.go
+index 1111111..2222222 100644
+--- a/foo.go
++++ b/foo.go
+@@ -1,3 +1,4 @@
+ package foo
++import "fmt"
+
+ func Foo() {}
+@@ -10,2 +11,2 @@ func Bar() {
+-	return 1
++	return 2
+ }
+`
+
+	patch, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(patch.Hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(patch.Hunks))
+	}
+
+	h1 := patch.Hunks[0]
+	if h1.OrigStartLine != 1 || h1.OrigLines != 3 || h1.NewStartLine != 1 || h1.NewLines != 4 {
+		t.Fatalf("unexpected hunk bounds: %+v", h1)
+	}
+	if len(h1.Lines) != 4 {
+		t.Fatalf("expected 4 lines in first hunk, got %d", len(h1.Lines))
+	}
+	if h1.Lines[1].Kind != Added || h1.Lines[1].NewLineNo != 2 {
+		t.Fatalf("expected added line at new line 2, got %+v", h1.Lines[1])
+	}
+
+	h2 := patch.Hunks[1]
+	if h2.Lines[0].Kind != Removed || h2.Lines[0].OrigLineNo != 10 {
+		t.Fatalf("expected removed line at orig line 10, got %+v", h2.Lines[0])
+	}
+	if h2.Lines[1].Kind != Added || h2.Lines[1].NewLineNo != 11 {
+		t.Fatalf("expected added line at new line 11, got %+v", h2.Lines[1])
+	}
+}
+
+func TestParseNoNewlineMarker(t *testing.T) {
+	raw := `diff --git a/foo.go b/foo.go
+--- a/foo.go
++++ b/foo.go
+@@ -1,2 +1,2 @@
+ package foo
+-func Foo() {}
+\ No newline at end of file
++func Foo() {}
+\ No newline at end of file
+`
+
+	patch, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(patch.Hunks) != 1 {
+		t.Fatalf("expected 1 hunk, got %d", len(patch.Hunks))
+	}
+
+	hunk := patch.Hunks[0]
+
+	var markers int
+	for _, l := range hunk.Lines {
+		if l.Kind == NoNewline {
+			markers++
+		}
+	}
+	if markers != 2 {
+		t.Fatalf("expected 2 no-newline markers, got %d", markers)
+	}
+}
+
+func TestParseCombinedDiff(t *testing.T) {
+	raw := `diff --cc foo.go
+index 1111111,2222222..3333333
+--- a/foo.go
++++ b/foo.go
+@@@ -1,3 -1,3 +1,4 @@@
+  package foo
+++import "fmt"
+
+  func Foo() {}
+`
+
+	patch, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(patch.Hunks) != 1 {
+		t.Fatalf("expected 1 hunk, got %d", len(patch.Hunks))
+	}
+
+	hunk := patch.Hunks[0]
+	if hunk.Parents != 2 {
+		t.Fatalf("expected 2 parents, got %d", hunk.Parents)
+	}
+	if hunk.OrigStartLine != 1 || hunk.NewStartLine != 1 || hunk.NewLines != 4 {
+		t.Fatalf("unexpected hunk bounds: %+v", hunk)
+	}
+	if len(hunk.Lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d", len(hunk.Lines))
+	}
+	if hunk.Lines[1].Kind != Added {
+		t.Fatalf("expected the import line to be added, got %+v", hunk.Lines[1])
+	}
+}
+
+func TestPatchFindHunk(t *testing.T) {
+	patch := &Patch{
+		Hunks: []*Hunk{
+			{OrigStartLine: 1, OrigLines: 3},
+			{OrigStartLine: 10, OrigLines: 2},
+			{OrigStartLine: 20, OrigLines: 5},
+		},
+	}
+
+	tests := []struct {
+		line     int
+		expected int // expected hunk's OrigStartLine, or 0 for nil
+	}{
+		{line: 0, expected: 0},
+		{line: 1, expected: 1},
+		{line: 5, expected: 1},
+		{line: 10, expected: 10},
+		{line: 15, expected: 10},
+		{line: 25, expected: 20},
+	}
+
+	for _, test := range tests {
+		hunk := patch.FindHunk(test.line)
+		if test.expected == 0 {
+			if hunk != nil {
+				t.Errorf("line %d: expected nil hunk, got %+v", test.line, hunk)
+			}
+			continue
+		}
+		if hunk == nil || hunk.OrigStartLine != test.expected {
+			t.Errorf("line %d: expected hunk starting at %d, got %+v", test.line, test.expected, hunk)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package resolvers
+
+import (
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/diff"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+)
+
+// DefaultDiffCacheSize is the number of parsed file diffs retained by defaultDiffCache.
+const DefaultDiffCacheSize = 1000
+
+// defaultDiffCache is the process-wide DiffCache shared by every positionAdjuster
+// constructed via NewPositionAdjuster with a nil cache, so that hover/definition
+// requests against the same file across separate resolvers reuse each other's work.
+// Its counters are registered against the default registry exactly once; tests and
+// other independent callers should construct their own DiffCache with NewDiffCache
+// and a scoped prometheus.Registerer instead of reusing this one.
+var defaultDiffCache = NewDiffCache(DefaultDiffCacheSize, prometheus.DefaultRegisterer)
+
+// diffCacheKey uniquely identifies a parsed file diff. Because commit SHAs are
+// immutable, a key's cached value never needs to be invalidated -- it is only ever
+// evicted to make room for another entry.
+type diffCacheKey struct {
+	repoID       api.RepoID
+	sourceCommit string
+	targetCommit string
+	path         string
+}
+
+// DiffCache is a bounded, size-evicted cache of parsed file diffs, shared across
+// positionAdjuster instances to avoid re-running and re-parsing `git diff` for every
+// token resolved in a file.
+type DiffCache struct {
+	cache  *lru.Cache
+	hits   prometheus.Counter
+	misses prometheus.Counter
+}
+
+// NewDiffCache creates a DiffCache holding up to size parsed diffs, registering its hit
+// and miss counters against r. Use this (rather than defaultDiffCache) to give a test,
+// or an independent process component, its own cache instance and its own registry --
+// reusing a registry that already has a DiffCache's counters registered against it
+// panics on duplicate registration.
+func NewDiffCache(size int, r prometheus.Registerer) *DiffCache {
+	cache, err := lru.New(size)
+	if err != nil {
+		// Only occurs when size <= 0, which is a programmer error.
+		panic(err)
+	}
+
+	factory := promauto.With(r)
+
+	return &DiffCache{
+		cache: cache,
+		hits: factory.NewCounter(prometheus.CounterOpts{
+			Name: "src_codeintel_diff_cache_hits_total",
+			Help: "The number of cache hits for parsed file diffs used in position adjustment.",
+		}),
+		misses: factory.NewCounter(prometheus.CounterOpts{
+			Name: "src_codeintel_diff_cache_misses_total",
+			Help: "The number of cache misses for parsed file diffs used in position adjustment.",
+		}),
+	}
+}
+
+// Get returns the cached patch for the given repository, commit pair, and path, if any.
+func (c *DiffCache) Get(repoID api.RepoID, sourceCommit, targetCommit, path string) (*diff.Patch, bool) {
+	key := diffCacheKey{repoID: repoID, sourceCommit: sourceCommit, targetCommit: targetCommit, path: path}
+
+	v, ok := c.cache.Get(key)
+	if !ok {
+		c.misses.Inc()
+		return nil, false
+	}
+
+	c.hits.Inc()
+	return v.(*diff.Patch), true
+}
+
+// Set stores the parsed patch for the given repository, commit pair, and path, evicting
+// the least recently used entry if the cache is already full.
+func (c *DiffCache) Set(repoID api.RepoID, sourceCommit, targetCommit, path string, patch *diff.Patch) {
+	key := diffCacheKey{repoID: repoID, sourceCommit: sourceCommit, targetCommit: targetCommit, path: path}
+	c.cache.Add(key, patch)
+}
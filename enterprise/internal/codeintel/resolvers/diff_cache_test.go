@@ -0,0 +1,51 @@
+package resolvers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/diff"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+)
+
+func TestDiffCacheGetSet(t *testing.T) {
+	cache := NewDiffCache(2, prometheus.NewRegistry())
+
+	if _, ok := cache.Get(api.RepoID(1), "a", "b", "foo.go"); ok {
+		t.Fatalf("expected cache miss")
+	}
+
+	patch := &diff.Patch{Hunks: []*diff.Hunk{{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1}}}
+	cache.Set(api.RepoID(1), "a", "b", "foo.go", patch)
+
+	got, ok := cache.Get(api.RepoID(1), "a", "b", "foo.go")
+	if !ok {
+		t.Fatalf("expected cache hit")
+	}
+	if got != patch {
+		t.Fatalf("expected cached patch to be returned unchanged")
+	}
+
+	// A different path is a distinct key.
+	if _, ok := cache.Get(api.RepoID(1), "a", "b", "bar.go"); ok {
+		t.Fatalf("expected cache miss for different path")
+	}
+}
+
+func TestDiffCacheEviction(t *testing.T) {
+	cache := NewDiffCache(1, prometheus.NewRegistry())
+
+	patchA := &diff.Patch{}
+	patchB := &diff.Patch{}
+
+	cache.Set(api.RepoID(1), "a", "b", "foo.go", patchA)
+	cache.Set(api.RepoID(1), "a", "b", "bar.go", patchB)
+
+	if _, ok := cache.Get(api.RepoID(1), "a", "b", "foo.go"); ok {
+		t.Fatalf("expected least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get(api.RepoID(1), "a", "b", "bar.go"); !ok {
+		t.Fatalf("expected most recently set entry to still be cached")
+	}
+}
@@ -0,0 +1,157 @@
+package resolvers
+
+import (
+	"regexp"
+
+	bundles "github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/bundles/client"
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/diff"
+)
+
+// Confidence describes how sure a FuzzyAdjustPosition translation is.
+type Confidence int
+
+const (
+	// None indicates that no translation -- exact or fuzzy -- could be found.
+	None Confidence = iota
+	// Fuzzy indicates that the target line was edited, but a similar line elsewhere in
+	// the same hunk was found and used instead.
+	Fuzzy
+	// Exact indicates that the target line was unchanged and translated precisely.
+	Exact
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case Exact:
+		return "exact"
+	case Fuzzy:
+		return "fuzzy"
+	default:
+		return "none"
+	}
+}
+
+// defaultFuzzyMatchThreshold is used in place of a zero PositionAdjusterOptions.FuzzyMatchThreshold.
+const defaultFuzzyMatchThreshold = 0.5
+
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// fuzzyAdjustPosition first attempts the exact translation performed by adjustPosition.
+// If that fails and opts.EnableFuzzyMatching is set, it falls back to scoring every other
+// line in the same hunk that has an equivalent on the result side (i.e. the hunk's
+// added/context lines when translating forward, or its removed/context lines when
+// translating in reverse) against the tokens of the edited source line, by longest common
+// subsequence of identifier tokens. The highest-scoring candidate, if its score is at or
+// above opts.FuzzyMatchThreshold, is returned with Fuzzy confidence; otherwise None is
+// returned.
+func fuzzyAdjustPosition(hunks []*diff.Hunk, pos bundles.Position, reverse bool, opts PositionAdjusterOptions) (bundles.Position, Confidence) {
+	if adjusted, ok := adjustPosition(hunks, pos, reverse); ok {
+		return adjusted, Exact
+	}
+	if !opts.EnableFuzzyMatching {
+		return bundles.Position{}, None
+	}
+
+	line := pos.Line + 1
+
+	for _, hunk := range hunks {
+		searchStart, searchEnd, _ := hunkSearchBounds(hunk, reverse)
+		if line < searchStart || line >= searchEnd {
+			continue
+		}
+
+		sourceLine, ok := findLineAt(hunk, line, reverse)
+		if !ok {
+			// The line falls within the hunk's range but has no entry of its own; the
+			// hunk body is malformed in the same way adjustPosition guards against.
+			return bundles.Position{}, None
+		}
+
+		sourceTokens := identifierPattern.FindAllString(sourceLine.Content, -1)
+
+		threshold := opts.FuzzyMatchThreshold
+		if threshold <= 0 {
+			threshold = defaultFuzzyMatchThreshold
+		}
+
+		bestScore := -1.0
+		bestResultLineNo := 0
+
+		for _, candidate := range hunk.Lines {
+			resultLineNo := candidate.NewLineNo
+			if reverse {
+				resultLineNo = candidate.OrigLineNo
+			}
+			if resultLineNo == 0 || candidate == sourceLine {
+				continue
+			}
+
+			score := tokenSimilarity(sourceTokens, identifierPattern.FindAllString(candidate.Content, -1))
+			if score > bestScore {
+				bestScore = score
+				bestResultLineNo = resultLineNo
+			}
+		}
+
+		if bestResultLineNo == 0 || bestScore < threshold {
+			return bundles.Position{}, None
+		}
+
+		return bundles.Position{Line: bestResultLineNo - 1, Character: pos.Character}, Fuzzy
+	}
+
+	return bundles.Position{}, None
+}
+
+// findLineAt returns the hunk line whose line number on the side being searched matches
+// line, along with whether one was found.
+func findLineAt(hunk *diff.Hunk, line int, reverse bool) (diff.Line, bool) {
+	for _, l := range hunk.Lines {
+		searchLineNo := l.OrigLineNo
+		if reverse {
+			searchLineNo = l.NewLineNo
+		}
+		if searchLineNo == line {
+			return l, true
+		}
+	}
+	return diff.Line{}, false
+}
+
+// tokenSimilarity scores two identifier token sequences by the length of their longest
+// common subsequence, normalized by the longer sequence's length so the result falls in
+// [0, 1]. Two empty sequences are considered identical.
+func tokenSimilarity(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	longest := longestCommonSubsequenceLength(a, b)
+
+	denom := len(a)
+	if len(b) > denom {
+		denom = len(b)
+	}
+	return float64(longest) / float64(denom)
+}
+
+func longestCommonSubsequenceLength(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else if dp[i-1][j] >= dp[i][j-1] {
+				dp[i][j] = dp[i-1][j]
+			} else {
+				dp[i][j] = dp[i][j-1]
+			}
+		}
+	}
+
+	return dp[len(a)][len(b)]
+}
@@ -0,0 +1,99 @@
+package resolvers
+
+import (
+	"testing"
+
+	bundles "github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/bundles/client"
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/diff"
+)
+
+func TestFuzzyAdjustPositionDisabledByDefault(t *testing.T) {
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 5, OrigLines: 1, NewStartLine: 5, NewLines: 1,
+			Lines: []diff.Line{
+				{Kind: diff.Removed, OrigLineNo: 5, Content: "func Foo() {"},
+				{Kind: diff.Added, NewLineNo: 5, Content: "func Foo() {"},
+			},
+		},
+	}
+
+	_, confidence := fuzzyAdjustPosition(hunks, bundles.Position{Line: 4}, false, PositionAdjusterOptions{})
+	if confidence != None {
+		t.Fatalf("expected fuzzy matching to be disabled by default, got %s", confidence)
+	}
+}
+
+func TestFuzzyAdjustPositionWhitespaceEdit(t *testing.T) {
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 5, OrigLines: 1, NewStartLine: 5, NewLines: 1,
+			Lines: []diff.Line{
+				{Kind: diff.Removed, OrigLineNo: 5, Content: "func Foo() {"},
+				{Kind: diff.Added, NewLineNo: 5, Content: "func Foo() {  "},
+			},
+		},
+	}
+
+	pos, confidence := fuzzyAdjustPosition(hunks, bundles.Position{Line: 4, Character: 3}, false, PositionAdjusterOptions{EnableFuzzyMatching: true})
+	if confidence != Fuzzy {
+		t.Fatalf("expected a fuzzy match, got %s", confidence)
+	}
+	if want := (bundles.Position{Line: 4, Character: 3}); pos != want {
+		t.Fatalf("expected %+v, got %+v", want, pos)
+	}
+}
+
+func TestFuzzyAdjustPositionSingleTokenRename(t *testing.T) {
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 10, OrigLines: 1, NewStartLine: 10, NewLines: 1,
+			Lines: []diff.Line{
+				{Kind: diff.Removed, OrigLineNo: 10, Content: "func Foo(ctx context.Context) error {"},
+				{Kind: diff.Added, NewLineNo: 10, Content: "func Bar(ctx context.Context) error {"},
+			},
+		},
+	}
+
+	_, confidence := fuzzyAdjustPosition(hunks, bundles.Position{Line: 9}, false, PositionAdjusterOptions{EnableFuzzyMatching: true})
+	if confidence != Fuzzy {
+		t.Fatalf("expected a fuzzy match for a single renamed token, got %s", confidence)
+	}
+}
+
+func TestFuzzyAdjustPositionCompleteRewrite(t *testing.T) {
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 1,
+			Lines: []diff.Line{
+				{Kind: diff.Removed, OrigLineNo: 1, Content: "func Foo(ctx context.Context) error {"},
+				{Kind: diff.Added, NewLineNo: 1, Content: "type Unrelated struct { Field int }"},
+			},
+		},
+	}
+
+	_, confidence := fuzzyAdjustPosition(hunks, bundles.Position{Line: 0}, false, PositionAdjusterOptions{EnableFuzzyMatching: true})
+	if confidence != None {
+		t.Fatalf("expected a complete rewrite to have no fuzzy match, got %s", confidence)
+	}
+}
+
+func TestFuzzyAdjustPositionPrefersExact(t *testing.T) {
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 1, OrigLines: 2, NewStartLine: 1, NewLines: 2,
+			Lines: []diff.Line{
+				{Kind: diff.Context, OrigLineNo: 1, NewLineNo: 1, Content: "package foo"},
+				{Kind: diff.Context, OrigLineNo: 2, NewLineNo: 2, Content: "func Foo() {}"},
+			},
+		},
+	}
+
+	pos, confidence := fuzzyAdjustPosition(hunks, bundles.Position{Line: 0}, false, PositionAdjusterOptions{EnableFuzzyMatching: true})
+	if confidence != Exact {
+		t.Fatalf("expected an unedited line to translate exactly, got %s", confidence)
+	}
+	if want := (bundles.Position{Line: 0}); pos != want {
+		t.Fatalf("expected %+v, got %+v", want, pos)
+	}
+}
@@ -1,15 +1,13 @@
 package resolvers
 
 import (
-	"bytes"
 	"context"
 	"io/ioutil"
-	"strings"
 
-	"github.com/sourcegraph/go-diff/diff"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	bundles "github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/bundles/client"
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/diff"
 	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
 )
 
@@ -32,18 +30,48 @@ type PositionAdjuster interface {
 	// that the translation was successful. If revese is true, then the source and target commits
 	// are swapped.
 	AdjustRange(ctx context.Context, commit, path string, rx bundles.Range, reverse bool) (string, bundles.Range, bool, error)
+
+	// FuzzyAdjustPosition behaves like AdjustPosition, but if the exact translation fails
+	// because the target line was edited, and fuzzy matching is enabled in this adjuster's
+	// PositionAdjusterOptions, it falls back to a best-effort intra-hunk match and reports
+	// its Confidence instead of failing outright.
+	FuzzyAdjustPosition(ctx context.Context, commit, path string, px bundles.Position, reverse bool) (string, bundles.Position, Confidence, error)
+}
+
+// PositionAdjusterOptions configures the optional, more expensive behaviors of a
+// PositionAdjuster.
+type PositionAdjusterOptions struct {
+	// EnableFuzzyMatching opts into the FuzzyAdjustPosition fallback described there. It
+	// is off by default because it is a heuristic: callers that need a precise-or-nothing
+	// translation should leave this unset and use AdjustPosition.
+	EnableFuzzyMatching bool
+
+	// FuzzyMatchThreshold is the minimum token-LCS similarity (in [0, 1]) a candidate line
+	// must reach to be reported as a Fuzzy match. Zero selects defaultFuzzyMatchThreshold.
+	FuzzyMatchThreshold float64
 }
 
 type positionAdjuster struct {
 	repo   *types.Repo
 	commit string
+	cache  *DiffCache
+	opts   PositionAdjusterOptions
 }
 
-// NewPositionAdjuster creates a new PositionAdjuster with the given repository and source commit.
-func NewPositionAdjuster(repo *types.Repo, commit string) PositionAdjuster {
+// NewPositionAdjuster creates a new PositionAdjuster with the given repository and source
+// commit. Parsed diffs are cached in the given DiffCache; pass nil to share the
+// process-wide defaultDiffCache, or a fresh DiffCache to isolate a test or a component
+// that wants its own eviction budget.
+func NewPositionAdjuster(repo *types.Repo, commit string, cache *DiffCache, opts PositionAdjusterOptions) PositionAdjuster {
+	if cache == nil {
+		cache = defaultDiffCache
+	}
+
 	return &positionAdjuster{
 		repo:   repo,
 		commit: commit,
+		cache:  cache,
+		opts:   opts,
 	}
 }
 
@@ -55,41 +83,59 @@ func (p *positionAdjuster) AdjustPath(ctx context.Context, commit, path string,
 
 // AdjustPosition translates the given position from the source commit into the given
 // target commit. The adjusted path and position are returned, along with a boolean flag
-// indicating that the translation was successful. If revese is true, then the source and
-// target commits are swapped.
+// indicating that the translation was successful. If reverse is true, px is instead
+// interpreted as a position in the target commit and is translated back into the source
+// commit.
 func (p *positionAdjuster) AdjustPosition(ctx context.Context, commit, path string, px bundles.Position, reverse bool) (string, bundles.Position, bool, error) {
-	hunks, err := p.readHunks(ctx, p.repo, p.commit, commit, path, reverse)
+	hunks, err := p.readHunks(ctx, p.repo, p.commit, commit, path)
 	if err != nil {
 		return "", bundles.Position{}, false, err
 	}
 
-	adjusted, ok := adjustPosition(hunks, px)
+	adjusted, ok := adjustPosition(hunks, px, reverse)
 	return path, adjusted, ok, nil
 }
 
 // AdjustRange translates the given range from the source commit into the given target
 // commit. The adjusted path and range are returned, along with a boolean flag indicating
-// that the translation was successful. If revese is true, then the source and target commits
-// are swapped.
+// that the translation was successful. If reverse is true, rx is instead interpreted as a
+// range in the target commit and is translated back into the source commit.
 func (p *positionAdjuster) AdjustRange(ctx context.Context, commit, path string, rx bundles.Range, reverse bool) (string, bundles.Range, bool, error) {
-	hunks, err := p.readHunks(ctx, p.repo, p.commit, commit, path, reverse)
+	hunks, err := p.readHunks(ctx, p.repo, p.commit, commit, path)
 	if err != nil {
 		return "", bundles.Range{}, false, err
 	}
 
-	adjusted, ok := adjustRange(hunks, rx)
+	adjusted, ok := adjustRange(hunks, rx, reverse)
 	return path, adjusted, ok, nil
 }
 
-// readHunks returns a position-ordered slice of changes (additions or deletions) of the
-// given path between the given source and target commits. If revese is true, then the
-// source and target commits are swapped.
-func (p *positionAdjuster) readHunks(ctx context.Context, repo *types.Repo, sourceCommit, targetCommit, path string, reverse bool) ([]*diff.Hunk, error) {
+// FuzzyAdjustPosition behaves like AdjustPosition, but if the exact translation fails
+// because the target line was edited, and fuzzy matching is enabled in this adjuster's
+// PositionAdjusterOptions, it falls back to a best-effort intra-hunk match and reports its
+// Confidence instead of failing outright.
+func (p *positionAdjuster) FuzzyAdjustPosition(ctx context.Context, commit, path string, px bundles.Position, reverse bool) (string, bundles.Position, Confidence, error) {
+	hunks, err := p.readHunks(ctx, p.repo, p.commit, commit, path)
+	if err != nil {
+		return "", bundles.Position{}, None, err
+	}
+
+	adjusted, confidence := fuzzyAdjustPosition(hunks, px, reverse, p.opts)
+	return path, adjusted, confidence, nil
+}
+
+// readHunks returns the parsed hunks describing the changes to the given path between the
+// given source and target commits, always diffed in the source-to-target direction. The
+// direction in which a position or range is subsequently translated through these hunks is
+// controlled by the reverse flag passed to adjustPosition/adjustRange, not by this function,
+// so the same parsed (and cached) diff serves both directions.
+func (p *positionAdjuster) readHunks(ctx context.Context, repo *types.Repo, sourceCommit, targetCommit, path string) ([]*diff.Hunk, error) {
 	if sourceCommit == targetCommit {
 		return nil, nil
 	}
-	if reverse {
-		sourceCommit, targetCommit = targetCommit, sourceCommit
+
+	if patch, ok := p.cache.Get(repo.ID, sourceCommit, targetCommit, path); ok {
+		return patch.Hunks, nil
 	}
 
 	cachedRepo, err := backend.CachedGitRepo(ctx, repo)
@@ -97,7 +143,6 @@ func (p *positionAdjuster) readHunks(ctx context.Context, repo *types.Repo, sour
 		return nil, err
 	}
 
-	// TODO(efritz) - cache diff results
 	reader, err := git.ExecReader(ctx, *cachedRepo, []string{"diff", sourceCommit, targetCommit, "--", path})
 	if err != nil {
 		return nil, err
@@ -112,108 +157,101 @@ func (p *positionAdjuster) readHunks(ctx context.Context, repo *types.Repo, sour
 		return nil, nil
 	}
 
-	diff, err := diff.NewFileDiffReader(bytes.NewReader(output)).Read()
+	patch, err := diff.Parse(output)
 	if err != nil {
 		return nil, err
 	}
-	return diff.Hunks, nil
+
+	p.cache.Set(repo.ID, sourceCommit, targetCommit, path, patch)
+	return patch.Hunks, nil
 }
 
-// adjustPosition translates the given position by adjusting the line number based on the
-// number of additions and deletions that occur before that line. This function returns a
-// boolean flag indicating that the translation is successful. A translation fails when the
-// line indicated by the position has been edited.
-func adjustPosition(hunks []*diff.Hunk, pos bundles.Position) (bundles.Position, bool) {
+// adjustPosition translates the given position by sweeping every hunk that precedes it,
+// accumulating the net delta each one contributes to the line offset. This function
+// returns a boolean flag indicating that the translation is successful. A translation
+// fails when the line indicated by the position has been edited.
+//
+// hunks are always parsed from a source-to-target diff. When reverse is false, pos is
+// read as a line in that diff's source file and the returned position is a line in its
+// target file. When reverse is true, the roles are swapped: pos is read as a line in the
+// target file and the returned position is a line in the source file. This mirrors
+// lazygit's PatchOptions.Reverse -- a line that was a `+` addition in the forward diff has
+// no source-file equivalent, so translating it in reverse correctly fails instead of
+// reporting the mirror-image (and wrong) line.
+func adjustPosition(hunks []*diff.Hunk, pos bundles.Position, reverse bool) (bundles.Position, bool) {
 	// Translate from bundle/lsp zero-index to git diff one-index
 	line := pos.Line + 1
 
-	hunk := findHunk(hunks, line)
-	if hunk == nil {
-		// Trivial case, no changes before this line
-		return pos, true
-	}
-
-	// If the hunk ends before this line, we can simply adjust the line offset by the
-	// relative difference between the line offsets in each file after this hunk.
-	if line >= int(hunk.OrigStartLine+hunk.OrigLines) {
-		endOfSourceHunk := int(hunk.OrigStartLine + hunk.OrigLines)
-		endOfTargetHunk := int(hunk.NewStartLine + hunk.NewLines)
-		adjustedLine := line + (endOfTargetHunk - endOfSourceHunk)
-
-		// Translate from git diff one-index to bundle/lsp zero-index
-		return bundles.Position{Line: adjustedLine - 1, Character: pos.Character}, true
-	}
+	// runningDelta accumulates the net line-count delta of every hunk fully before our
+	// target line, so that a line N hunks deep in a churny file still lands on the correct
+	// offset rather than just the offset contributed by the nearest preceding hunk.
+	runningDelta := 0
 
-	// These offsets start at the beginning of the hunk's delta. The following loop will
-	// process the delta line-by-line. For each line that exists the source (orig) or
-	// target (new) file, the corresponding offset will be bumped. The values of these
-	// offsets once we hit our target line will determine the relative offset between
-	// the two files.
-	sourceOffset := int(hunk.OrigStartLine)
-	targetOffset := int(hunk.NewStartLine)
+	for _, hunk := range hunks {
+		searchStart, searchEnd, delta := hunkSearchBounds(hunk, reverse)
 
-	for _, deltaLine := range strings.Split(string(hunk.Body), "\n") {
-		isAdded := strings.HasPrefix(deltaLine, "+")
-		isRemoved := strings.HasPrefix(deltaLine, "-")
+		if line < searchStart {
+			break
+		}
 
-		// A line exists in the source file if it wasn't added in the delta. We adjust
-		// this before the next condition so that our comparison with our target line
-		// is correct.
-		if !isAdded {
-			sourceOffset++
+		if line >= searchEnd {
+			runningDelta += delta
+			continue
 		}
 
-		// Hit our target line
-		if sourceOffset-1 == line {
-			// This particular line was (1) edited; (2) removed, or (3) added.
-			// If it was removed, there is nothing to point to in the target file.
-			// If it was added, then we don't have any index information for it in
-			// our source file. In any case, we won't have a precise translation.
-			if isAdded || isRemoved {
+		// Our target line falls within this hunk. Walk its already-parsed lines to find
+		// the one with the matching line number on the side we're searching, then report
+		// its line number on the other side.
+		for _, deltaLine := range hunk.Lines {
+			searchLineNo, resultLineNo := deltaLine.OrigLineNo, deltaLine.NewLineNo
+			if reverse {
+				searchLineNo, resultLineNo = deltaLine.NewLineNo, deltaLine.OrigLineNo
+			}
+			if searchLineNo != line {
+				continue
+			}
+
+			// This particular line was (1) edited; (2) removed, or (3) added. If it
+			// has no equivalent on the other side, we won't have a precise translation.
+			if deltaLine.Kind != diff.Context {
 				return bundles.Position{}, false
 			}
 
 			// Translate from git diff one-index to bundle/lsp zero-index
-			return bundles.Position{Line: targetOffset - 1, Character: pos.Character}, true
+			return bundles.Position{Line: resultLineNo - 1, Character: pos.Character}, true
 		}
 
-		// A line exists in the target file if it wasn't deleted in the delta. We adjust
-		// this after the previous condition so we don't have to re-adjust the target offset
-		// within the exit conditions (this adjustment is only necessary for future iterations).
-		if !isRemoved {
-			targetOffset++
-		}
+		// This should never happen unless the git diff content is malformed. We know
+		// the target line occurs within the hunk, but iteration of the hunk's body did
+		// not contain enough lines attributed to the side we're searching.
+		panic("Malformed hunk body")
 	}
 
-	// This should never happen unless the git diff content is malformed. We know
-	// the target line occurs within the hunk, but iteration of the hunk's body did
-	// not contain enough lines attributed to the original file.
-	panic("Malformed hunk body")
+	// No changes fall directly on this line; apply the accumulated delta of every hunk
+	// that precedes it. Translate from git diff one-index to bundle/lsp zero-index.
+	return bundles.Position{Line: line + runningDelta - 1, Character: pos.Character}, true
 }
 
-// findHunk returns the last thunk that does not begin after the given line.
-func findHunk(hunks []*diff.Hunk, line int) *diff.Hunk {
-	i := 0
-	for i < len(hunks) && int(hunks[i].OrigStartLine) <= line {
-		i++
-	}
-
-	if i == 0 {
-		return nil
+// hunkSearchBounds returns the [start, end) line range of hunk on the side being searched,
+// along with the net delta it contributes to lines after it, for the given direction.
+func hunkSearchBounds(hunk *diff.Hunk, reverse bool) (searchStart, searchEnd, delta int) {
+	if reverse {
+		return hunk.NewStartLine, hunk.NewEndLine(), hunk.OrigLines - hunk.NewLines
 	}
-	return hunks[i-1]
+	return hunk.OrigStartLine, hunk.OrigEndLine(), hunk.NewLines - hunk.OrigLines
 }
 
-// adjustRange translates the given range by calling adjustPosition on both of hte range's
-// endpoints. This function returns a boolean flag indicating that the translation was
-// successful (which occurs when both endpoints of the range can be translated).
-func adjustRange(hunks []*diff.Hunk, r bundles.Range) (bundles.Range, bool) {
-	start, ok := adjustPosition(hunks, r.Start)
+// adjustRange translates the given range by calling adjustPosition on both of the range's
+// endpoints, in the given direction. This function returns a boolean flag indicating that
+// the translation was successful (which occurs when both endpoints of the range can be
+// translated).
+func adjustRange(hunks []*diff.Hunk, r bundles.Range, reverse bool) (bundles.Range, bool) {
+	start, ok := adjustPosition(hunks, r.Start, reverse)
 	if !ok {
 		return bundles.Range{}, false
 	}
 
-	end, ok := adjustPosition(hunks, r.End)
+	end, ok := adjustPosition(hunks, r.End, reverse)
 	if !ok {
 		return bundles.Range{}, false
 	}
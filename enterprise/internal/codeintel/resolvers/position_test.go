@@ -0,0 +1,168 @@
+package resolvers
+
+import (
+	"testing"
+
+	bundles "github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/bundles/client"
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/diff"
+)
+
+func TestAdjustPositionNoHunks(t *testing.T) {
+	pos, ok := adjustPosition(nil, bundles.Position{Line: 10, Character: 5}, false)
+	if !ok {
+		t.Fatalf("expected ok")
+	}
+	if pos != (bundles.Position{Line: 10, Character: 5}) {
+		t.Fatalf("unexpected position: %+v", pos)
+	}
+}
+
+func TestAdjustPositionAccumulatesAcrossHunks(t *testing.T) {
+	// Three hunks with a non-monotonic mix of net-positive and net-negative deltas along
+	// the way:
+	//
+	//   hunk 1: lines 1-2   -> 1-4   (+2)
+	//   hunk 2: lines 10-12 -> 12-13 (-1)
+	//   hunk 3: lines 20-20 -> 19-21 (+2)
+	//
+	// A line well past all three hunks should reflect the sum of all three deltas, not
+	// just the delta of the nearest preceding hunk.
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 1, OrigLines: 2, NewStartLine: 1, NewLines: 4,
+			Lines: []diff.Line{
+				{Kind: diff.Context, OrigLineNo: 1, NewLineNo: 1},
+				{Kind: diff.Added, NewLineNo: 2},
+				{Kind: diff.Added, NewLineNo: 3},
+				{Kind: diff.Context, OrigLineNo: 2, NewLineNo: 4},
+			},
+		},
+		{
+			OrigStartLine: 10, OrigLines: 3, NewStartLine: 12, NewLines: 2,
+			Lines: []diff.Line{
+				{Kind: diff.Context, OrigLineNo: 10, NewLineNo: 12},
+				{Kind: diff.Removed, OrigLineNo: 11},
+				{Kind: diff.Context, OrigLineNo: 12, NewLineNo: 13},
+			},
+		},
+		{
+			OrigStartLine: 20, OrigLines: 1, NewStartLine: 19, NewLines: 3,
+			Lines: []diff.Line{
+				{Kind: diff.Added, NewLineNo: 19},
+				{Kind: diff.Context, OrigLineNo: 20, NewLineNo: 20},
+				{Kind: diff.Added, NewLineNo: 21},
+			},
+		},
+	}
+
+	// Line 30 (one-indexed) is past every hunk; expected delta is (4-2) + (2-3) + (3-1) = 3.
+	pos, ok := adjustPosition(hunks, bundles.Position{Line: 29, Character: 7}, false)
+	if !ok {
+		t.Fatalf("expected ok")
+	}
+	if want := (bundles.Position{Line: 32, Character: 7}); pos != want {
+		t.Fatalf("expected %+v, got %+v", want, pos)
+	}
+
+	// Line 6 (one-indexed) is after hunk 1 but before hunk 2; expected delta is just +2.
+	pos, ok = adjustPosition(hunks, bundles.Position{Line: 5, Character: 0}, false)
+	if !ok {
+		t.Fatalf("expected ok")
+	}
+	if want := (bundles.Position{Line: 7, Character: 0}); pos != want {
+		t.Fatalf("expected %+v, got %+v", want, pos)
+	}
+}
+
+func TestAdjustPositionEditedLine(t *testing.T) {
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 5, OrigLines: 1, NewStartLine: 5, NewLines: 1,
+			Lines: []diff.Line{
+				{Kind: diff.Removed, OrigLineNo: 5},
+				{Kind: diff.Added, NewLineNo: 5},
+			},
+		},
+	}
+
+	if _, ok := adjustPosition(hunks, bundles.Position{Line: 4, Character: 0}, false); ok {
+		t.Fatalf("expected translation of an edited line to fail")
+	}
+}
+
+func TestAdjustRange(t *testing.T) {
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 2,
+			Lines: []diff.Line{
+				{Kind: diff.Context, OrigLineNo: 1, NewLineNo: 1},
+				{Kind: diff.Added, NewLineNo: 2},
+			},
+		},
+	}
+
+	r, ok := adjustRange(hunks, bundles.Range{
+		Start: bundles.Position{Line: 4, Character: 0},
+		End:   bundles.Position{Line: 9, Character: 3},
+	}, false)
+	if !ok {
+		t.Fatalf("expected ok")
+	}
+	if want := (bundles.Range{
+		Start: bundles.Position{Line: 5, Character: 0},
+		End:   bundles.Position{Line: 10, Character: 3},
+	}); r != want {
+		t.Fatalf("expected %+v, got %+v", want, r)
+	}
+}
+
+func TestAdjustPositionRoundTrip(t *testing.T) {
+	// Forward: source lines 1-3 -> target lines 1-4 (one addition in the middle).
+	hunks := []*diff.Hunk{
+		{
+			OrigStartLine: 1, OrigLines: 3, NewStartLine: 1, NewLines: 4,
+			Lines: []diff.Line{
+				{Kind: diff.Context, OrigLineNo: 1, NewLineNo: 1},
+				{Kind: diff.Added, NewLineNo: 2},
+				{Kind: diff.Context, OrigLineNo: 2, NewLineNo: 3},
+				{Kind: diff.Context, OrigLineNo: 3, NewLineNo: 4},
+			},
+		},
+	}
+
+	// An unchanged line round-trips: forward then backward returns the original position.
+	fwd, ok := adjustPosition(hunks, bundles.Position{Line: 1, Character: 2}, false)
+	if !ok {
+		t.Fatalf("expected forward translation to succeed")
+	}
+	if want := (bundles.Position{Line: 2, Character: 2}); fwd != want {
+		t.Fatalf("expected %+v, got %+v", want, fwd)
+	}
+
+	back, ok := adjustPosition(hunks, fwd, true)
+	if !ok {
+		t.Fatalf("expected reverse translation to succeed")
+	}
+	if want := (bundles.Position{Line: 1, Character: 2}); back != want {
+		t.Fatalf("expected round-trip to recover %+v, got %+v", want, back)
+	}
+
+	// The line added in the target has no source-side equivalent: translating it in
+	// reverse must fail rather than silently reporting a mirror-image line.
+	if _, ok := adjustPosition(hunks, bundles.Position{Line: 1, Character: 0}, true); ok {
+		t.Fatalf("expected reverse translation of an added line to fail")
+	}
+
+	// A line after the hunk round-trips through the accumulated (inverted) delta too.
+	fwdAfter, ok := adjustPosition(hunks, bundles.Position{Line: 9, Character: 0}, false)
+	if !ok {
+		t.Fatalf("expected forward translation to succeed")
+	}
+	backAfter, ok := adjustPosition(hunks, fwdAfter, true)
+	if !ok {
+		t.Fatalf("expected reverse translation to succeed")
+	}
+	if want := (bundles.Position{Line: 9, Character: 0}); backAfter != want {
+		t.Fatalf("expected round-trip to recover %+v, got %+v", want, backAfter)
+	}
+}